@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// generateScannerID returns a fresh random scanner ID, suitable for use as
+// the "scanner_id" half of an enrollment credential.
+func generateScannerID() (string, error) {
+	return randomHex(8)
+}
+
+// generateSecret returns a fresh random scanner secret, handed back to the
+// caller exactly once at enrollment time. Only its bcrypt hash is persisted.
+func generateSecret() (string, error) {
+	return randomHex(32)
+}
+
+// randomHex returns n random bytes hex-encoded. crypto/rand.Read only fails
+// if the OS's CSPRNG source is unavailable (e.g. a locked-down container
+// without /dev/urandom wired up); in that rare case we retry once against
+// /dev/urandom directly rather than silently falling back to a
+// non-cryptographic source.
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		f, openErr := os.Open("/dev/urandom")
+		if openErr != nil {
+			return "", fmt.Errorf("crypto/rand unavailable and /dev/urandom missing: %w", err)
+		}
+		defer f.Close()
+
+		if _, readErr := io.ReadFull(f, buf); readErr != nil {
+			return "", fmt.Errorf("crypto/rand unavailable: %w", readErr)
+		}
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashSecret returns the bcrypt hash of a scanner secret for storage
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckSecret reports whether secret matches the bcrypt hash on file.
+// bcrypt.CompareHashAndPassword runs in constant time with respect to the
+// secret being checked.
+func CheckSecret(hash, secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+// CertFingerprint returns the SHA-256 fingerprint of a client certificate,
+// hex-encoded, for pinning a scanner's credential to the specific cert it
+// enrolled with.
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
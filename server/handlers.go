@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/chanslor/ble-scanner/server/storage"
+	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 )
 
@@ -16,7 +22,9 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// APIKeyAuth middleware checks for valid API key
+// APIKeyAuth middleware checks for valid API key. This guards the
+// dashboard-facing admin endpoints; scanner-facing endpoints use
+// ScannerAuth instead.
 func APIKeyAuth(apiKey string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -40,18 +48,220 @@ func APIKeyAuth(apiKey string) func(http.Handler) http.Handler {
 	}
 }
 
+type contextKey string
+
+const scannerIDContextKey contextKey = "scanner_id"
+
+// ScannerIDFromContext returns the scanner identity stamped by ScannerAuth
+func ScannerIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(scannerIDContextKey).(string)
+	return id, ok
+}
+
+// ScannerAuth middleware authenticates a scanner per-request and stamps its
+// verified identity onto the request context, replacing the shared-API-key
+// trust model for scanner-facing endpoints. When mTLS is required (see
+// TLS_CLIENT_CA), the scanner's identity is instead taken from the verified
+// client certificate's CN and the Authorization header is not consulted. In
+// either mode, a credential enrolled with a pinned cert fingerprint (see
+// HandleEnroll) must be presented with that same certificate.
+func ScannerAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requireMTLS {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				http.Error(w, "Client certificate required", http.StatusUnauthorized)
+				return
+			}
+			cert := r.TLS.PeerCertificates[0]
+			cn := cert.Subject.CommonName
+			if cn == "" {
+				http.Error(w, "Client certificate missing CN", http.StatusUnauthorized)
+				return
+			}
+
+			// The cert being trusted by TLS_CLIENT_CA only proves the
+			// scanner was enrolled at some point - it says nothing about
+			// revocation, which lives in scanner_credentials. Enforce it
+			// here the same way the bearer-token branch below does, so
+			// DELETE /api/scanners/{id} revokes a scanner regardless of
+			// which auth mode it's using.
+			cred, err := store.GetScannerCredential(cn)
+			if err != nil {
+				log.Printf("Error loading scanner credential: %v", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if cred == nil || cred.RevokedAt.Valid {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if cred.CertFingerprint != "" && cred.CertFingerprint != CertFingerprint(cert) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scannerIDContextKey, cn)))
+			return
+		}
+
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		scannerID, secret, ok := strings.Cut(auth, ":")
+		if !ok || scannerID == "" || secret == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		cred, err := store.GetScannerCredential(scannerID)
+		if err != nil {
+			log.Printf("Error loading scanner credential: %v", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if cred == nil || cred.RevokedAt.Valid || !CheckSecret(cred.SecretHash, secret) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if cred.CertFingerprint != "" {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || CertFingerprint(r.TLS.PeerCertificates[0]) != cred.CertFingerprint {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scannerIDContextKey, scannerID)))
+	})
+}
+
+// HandleEnroll handles POST /api/enroll - exchanges a one-time enrollment
+// token for a freshly generated scanner ID and secret. The secret is
+// returned exactly once; only its bcrypt hash is persisted. If the caller
+// presents a client certificate while enrolling (only possible when
+// TLS_CLIENT_CA is set), the credential is pinned to that certificate's
+// fingerprint, so future requests - bearer or mTLS - must present the same
+// cert.
+func HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	if enrollToken == "" {
+		http.Error(w, "Enrollment disabled", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+		Name  string `json:"name,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token != enrollToken {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scannerID, err := generateScannerID()
+	if err != nil {
+		log.Printf("Error generating scanner ID: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		log.Printf("Error generating scanner secret: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	hash, err := HashSecret(secret)
+	if err != nil {
+		log.Printf("Error hashing scanner secret: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	ip := r.Header.Get("X-Forwarded-For")
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+
+	if err := store.UpsertScanner(scannerID, req.Name, ip); err != nil {
+		log.Printf("Error creating scanner: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var certFingerprint string
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		certFingerprint = CertFingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	if err := store.InsertScannerCredential(scannerID, hash, certFingerprint); err != nil {
+		log.Printf("Error storing scanner credential: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Enrolled new scanner %s", scannerID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scanner_id": scannerID,
+		"secret":     secret,
+		"credential": scannerID + ":" + secret,
+	})
+}
+
+// HandleRevokeScanner handles DELETE /api/scanners/{id} - revokes a
+// scanner's enrollment credential so it can no longer authenticate
+func HandleRevokeScanner(w http.ResponseWriter, r *http.Request) {
+	scannerID := chi.URLParam(r, "id")
+
+	if err := store.RevokeScanner(scannerID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Scanner not found or already revoked", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error revoking scanner %s: %v", scannerID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Revoked scanner %s", scannerID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"revoked": scannerID,
+	})
+}
+
 // HandlePostLogs handles POST /api/logs - receive logs from scanner
 func HandlePostLogs(w http.ResponseWriter, r *http.Request) {
-	var batch LogBatch
+	decodeStart := time.Now()
+	var batch storage.LogBatch
 
 	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
 		log.Printf("Error decoding log batch: %v", err)
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	decodeDur := time.Since(decodeStart)
+
+	// The scanner's identity comes from ScannerAuth, not the request body -
+	// a scanner can no longer post logs under another scanner's ID.
+	scannerID, ok := ScannerIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	batch.ScannerID = scannerID
 
-	if batch.ScannerID == "" {
-		http.Error(w, "scanner_id required", http.StatusBadRequest)
+	// Per-scanner rate limit: tell the scanner exactly how long to back off
+	// rather than letting it hammer the server on rejection.
+	if allowed, retryAfter := ingestLimiter.Allow(scannerID); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
 
@@ -62,31 +272,97 @@ func HandlePostLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update scanner record
-	if err := UpsertScanner(batch.ScannerID, "", ip); err != nil {
+	if err := store.UpsertScanner(batch.ScannerID, "", ip); err != nil {
 		log.Printf("Error updating scanner: %v", err)
 	}
 
+	// Check the batch against the scanner's last accepted sequence so a
+	// reconnecting scanner can be told exactly where to resume from its
+	// on-disk queue, without duplicating or dropping rows.
+	lastSeq, err := store.GetScannerSequence(batch.ScannerID)
+	if err != nil {
+		log.Printf("Error reading scanner sequence: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if batch.Sequence <= lastSeq {
+		// Already applied (or a retransmit of an older batch); ignore it
+		// idempotently rather than erroring so a racing retry is harmless.
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "ok",
+			"received": 0,
+		})
+		return
+	}
+
+	if batch.Sequence > lastSeq+1 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":            "sequence_gap",
+			"expected_sequence": lastSeq + 1,
+		})
+		return
+	}
+
+	// Global cap on concurrent inserts, independent of per-scanner rate
+	// limiting, so a burst across many scanners at once can't overrun the
+	// database. Retry-After here is a short fixed value: the semaphore
+	// frees up on the next completed insert rather than on a schedule.
+	select {
+	case insertSemaphore <- struct{}{}:
+	default:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server busy, retry shortly", http.StatusTooManyRequests)
+		return
+	}
+
 	// Insert logs
-	count, err := InsertLogBatch(batch)
+	insertStart := time.Now()
+	count, err := store.InsertLogBatch(batch)
+	insertDur := time.Since(insertStart)
+
+	// Release the DB-capacity slot as soon as the insert finishes. The
+	// broadcast/logging steps below are unrelated WebSocket I/O and must
+	// not hold a slot that other scanners' inserts are waiting on -
+	// especially under DEBUG_PERF, where broadcast runs inline.
+	<-insertSemaphore
+
 	if err != nil {
 		log.Printf("Error inserting logs: %v", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Received %d logs from scanner %s", count, batch.ScannerID)
+	log.Printf("Received %d logs from scanner %s (batch=%s seq=%d)", count, batch.ScannerID, batch.BatchID, batch.Sequence)
+
+	// Broadcast to WebSocket clients. Normally fire-and-forget so the
+	// response isn't held up by it; under DEBUG_PERF we run it inline
+	// instead so its duration can be logged below.
+	broadcastFn := func() {
+		BroadcastNewLogs(batch.Devices)
 
-	// Broadcast to WebSocket clients
+		if stats, err := currentStats(); err == nil {
+			BroadcastStats(stats)
+		}
+	}
+
+	var broadcastDur time.Duration
 	if count > 0 {
-		go func() {
-			// Send the new logs
-			BroadcastNewLogs(batch.Devices)
+		if debugPerf {
+			broadcastStart := time.Now()
+			broadcastFn()
+			broadcastDur = time.Since(broadcastStart)
+		} else {
+			go broadcastFn()
+		}
+	}
 
-			// Also send updated stats
-			if stats, err := GetStats(); err == nil {
-				BroadcastStats(stats)
-			}
-		}()
+	if debugPerf {
+		log.Printf("ingest scanner=%s decode=%s insert=%s rows=%d broadcast=%s",
+			batch.ScannerID, decodeDur, insertDur, count, broadcastDur)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -110,13 +386,13 @@ func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 
 	scannerID := r.URL.Query().Get("scanner_id")
 
-	var logs []LogEntry
+	var logs []storage.LogEntry
 	var err error
 
 	if scannerID != "" {
-		logs, err = GetLogsByScanner(scannerID, limit)
+		logs, err = store.GetLogsByScanner(scannerID, limit)
 	} else {
-		logs, err = GetRecentLogs(limit)
+		logs, err = store.GetRecentLogs(limit)
 	}
 
 	if err != nil {
@@ -131,7 +407,7 @@ func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 
 // HandleClearLogs handles DELETE /api/logs - clear all logs
 func HandleClearLogs(w http.ResponseWriter, r *http.Request) {
-	deleted, err := ClearLogs()
+	deleted, err := store.ClearLogs()
 	if err != nil {
 		log.Printf("Error clearing logs: %v", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -147,9 +423,37 @@ func HandleClearLogs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleGetCheckpoint handles GET /api/scanners/{id}/checkpoint - returns the
+// highest batch sequence accepted for a scanner, so it knows where to
+// resume replaying its on-disk queue after reconnecting.
+func HandleGetCheckpoint(w http.ResponseWriter, r *http.Request) {
+	scannerID := chi.URLParam(r, "id")
+
+	// A scanner may only read its own checkpoint - ScannerAuth verifies the
+	// credential, but doesn't stop the path param from naming a different id.
+	authedID, _ := ScannerIDFromContext(r.Context())
+	if authedID != scannerID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	seq, err := store.GetScannerSequence(scannerID)
+	if err != nil {
+		log.Printf("Error fetching checkpoint for scanner %s: %v", scannerID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scanner_id":    scannerID,
+		"last_sequence": seq,
+	})
+}
+
 // HandleGetScanners handles GET /api/scanners - list scanners
 func HandleGetScanners(w http.ResponseWriter, r *http.Request) {
-	scanners, err := GetScanners()
+	scanners, err := store.GetScanners()
 	if err != nil {
 		log.Printf("Error fetching scanners: %v", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -160,9 +464,22 @@ func HandleGetScanners(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(scanners)
 }
 
+// currentStats merges database stats with the live ingest rate-limit state,
+// so the dashboard can see which scanners are currently being throttled.
+func currentStats() (map[string]interface{}, error) {
+	stats, err := store.GetStats()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range ingestLimiter.Snapshot() {
+		stats[k] = v
+	}
+	return stats, nil
+}
+
 // HandleGetStats handles GET /api/stats - get dashboard stats
 func HandleGetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := GetStats()
+	stats, err := currentStats()
 	if err != nil {
 		log.Printf("Error fetching stats: %v", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -173,6 +490,14 @@ func HandleGetStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// HandleDebugPerf handles GET /api/debug/perf - returns per-route latency
+// and throughput histograms collected by PerfMiddleware, for diagnosing
+// where time is going under load.
+func HandleDebugPerf(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(perfRegistry.Snapshot())
+}
+
 // HandleWebSocket handles WebSocket connections for live updates
 func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -185,6 +510,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		hub:  hub,
 		conn: conn,
 		send: make(chan []byte, 256),
+		done: make(chan struct{}),
 	}
 
 	hub.register <- client
@@ -192,7 +518,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Send initial data
 	go func() {
 		// Send recent logs
-		if logs, err := GetRecentLogs(50); err == nil {
+		if logs, err := store.GetRecentLogs(50); err == nil {
 			msg := WSMessage{Type: "initial_logs", Data: logs}
 			if data, err := json.Marshal(msg); err == nil {
 				client.send <- data
@@ -200,7 +526,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Send stats
-		if stats, err := GetStats(); err == nil {
+		if stats, err := currentStats(); err == nil {
 			msg := WSMessage{Type: "stats", Data: stats}
 			if data, err := json.Marshal(msg); err == nil {
 				client.send <- data
@@ -208,7 +534,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Send scanners
-		if scanners, err := GetScanners(); err == nil {
+		if scanners, err := store.GetScanners(); err == nil {
 			msg := WSMessage{Type: "scanners", Data: scanners}
 			if data, err := json.Marshal(msg); err == nil {
 				client.send <- data
@@ -4,10 +4,17 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/chanslor/ble-scanner/server/storage"
 	"github.com/gorilla/websocket"
 )
 
+// clientSendGrace is how long a client with a full send buffer gets to
+// drain it, after being warned with a "throttled" frame, before it's
+// dropped.
+const clientSendGrace = 2 * time.Second
+
 // Hub maintains active WebSocket connections and broadcasts messages
 type Hub struct {
 	clients    map[*Client]bool
@@ -22,6 +29,18 @@ type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// done is closed exactly once, by close(), to tell writePump to stop
+	// reading from send. send itself is never closed, so a deliverWithGrace
+	// goroutine racing with unregister can never send on a closed channel.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// close signals writePump to exit. Safe to call more than once or
+// concurrently with writePump returning on its own (e.g. a write error).
+func (c *Client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
 }
 
 // WSMessage is the structure for WebSocket messages
@@ -54,9 +73,9 @@ func (h *Hub) run() {
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
 			}
 			h.mu.Unlock()
+			client.close()
 			log.Printf("WebSocket client disconnected. Total: %d", len(h.clients))
 
 		case message := <-h.broadcast:
@@ -65,8 +84,15 @@ func (h *Hub) run() {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
-					delete(h.clients, client)
+					// Buffer is full - warn the client and give it a brief
+					// grace period to drain before dropping it, rather than
+					// closing on the first missed frame. This must not block
+					// the dispatch loop, so the wait runs in its own
+					// goroutine; one stalled client can no longer stall
+					// delivery to everyone else or the register/unregister
+					// cases waiting on h.mu.
+					warning, _ := json.Marshal(WSMessage{Type: "throttled", Data: map[string]string{"reason": "send_buffer_full"}})
+					go h.deliverWithGrace(client, warning, message)
 				}
 			}
 			h.mu.RUnlock()
@@ -74,13 +100,40 @@ func (h *Hub) run() {
 	}
 }
 
+// deliverWithGrace waits up to clientSendGrace in total for a slow client's
+// buffer to drain enough to accept the throttled warning (skipped if nil)
+// and then message, and unregisters the client if it doesn't. It runs in
+// its own goroutine, outside h.mu, so one stalled client can never block
+// delivery to the rest of h.clients or block register/unregister waiting
+// on the write lock.
+func (h *Hub) deliverWithGrace(client *Client, warning, message []byte) {
+	deadline := time.After(clientSendGrace)
+	for _, m := range [][]byte{warning, message} {
+		if m == nil {
+			continue
+		}
+		select {
+		case client.send <- m:
+		case <-client.done:
+			// Already unregistered by some other path; nothing left to do.
+			return
+		case <-deadline:
+			h.unregister <- client
+			return
+		}
+	}
+}
+
 func (c *Client) writePump() {
-	defer func() {
-		c.conn.Close()
-	}()
+	defer c.conn.Close()
 
-	for message := range c.send {
-		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+	for {
+		select {
+		case message := <-c.send:
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-c.done:
 			return
 		}
 	}
@@ -102,7 +155,7 @@ func (c *Client) readPump() {
 }
 
 // BroadcastNewLogs sends new log entries to all connected clients
-func BroadcastNewLogs(logs []LogEntry) {
+func BroadcastNewLogs(logs []storage.LogEntry) {
 	if hub == nil {
 		return
 	}
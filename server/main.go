@@ -1,12 +1,16 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"embed"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
+	"github.com/chanslor/ble-scanner/server/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -14,6 +18,25 @@ import (
 //go:embed static/*
 var staticFiles embed.FS
 
+// enrollToken gates POST /api/enroll; requireMTLS controls whether
+// ScannerAuth derives scanner identity from a verified client cert instead
+// of the Authorization header. ingestLimiter and insertSemaphore apply
+// backpressure to the ingest endpoint; see HandlePostLogs.
+var (
+	enrollToken     string
+	requireMTLS     bool
+	store           storage.Store
+	ingestLimiter   *IngestLimiter
+	insertSemaphore chan struct{}
+	debugPerf       bool
+)
+
+const (
+	defaultIngestRatePerSec     = 10
+	defaultIngestBurst          = 30
+	defaultMaxConcurrentInserts = 8
+)
+
 func main() {
 	// Configuration from environment
 	port := os.Getenv("PORT")
@@ -27,22 +50,51 @@ func main() {
 		log.Println("WARNING: Using default API key. Set API_KEY environment variable in production.")
 	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "/data/ble-scanner.db"
+	enrollToken = os.Getenv("ENROLL_TOKEN")
+	if enrollToken == "" {
+		log.Println("WARNING: ENROLL_TOKEN not set, POST /api/enroll is disabled.")
 	}
 
-	// Ensure data directory exists
-	if err := os.MkdirAll("/data", 0755); err != nil {
-		// Fallback to local directory for development
-		dbPath = "./ble-scanner.db"
-		log.Printf("Using local database: %s", dbPath)
+	dsn := os.Getenv("STORAGE_DSN")
+	if dsn == "" {
+		// Fall back to the old DB_PATH setting so existing sqlite
+		// deployments don't need a config change to upgrade.
+		dbPath := os.Getenv("DB_PATH")
+		if dbPath == "" {
+			dbPath = "/data/ble-scanner.db"
+		}
+		if err := os.MkdirAll("/data", 0755); err != nil {
+			// Fallback to local directory for development
+			dbPath = "./ble-scanner.db"
+			log.Printf("Using local database: %s", dbPath)
+		}
+		dsn = "sqlite://" + dbPath
 	}
 
-	// Initialize database
-	if err := initDB(dbPath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Initialize storage backend
+	var err error
+	store, err = storage.New(dsn)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	// Ingest backpressure: a per-scanner token bucket plus a global cap on
+	// concurrent inserts, so one noisy scanner or a write-heavy spike can't
+	// starve the others or overrun the database.
+	ratePerSec := envFloat("INGEST_RATE_PER_SEC", defaultIngestRatePerSec)
+	burst := envFloat("INGEST_BURST", defaultIngestBurst)
+	ingestLimiter = NewIngestLimiter(ratePerSec, burst)
+
+	maxConcurrentInserts := defaultMaxConcurrentInserts
+	if v := os.Getenv("INGEST_MAX_CONCURRENT_INSERTS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			maxConcurrentInserts = parsed
+		}
 	}
+	insertSemaphore = make(chan struct{}, maxConcurrentInserts)
+
+	debugPerf = os.Getenv("DEBUG_PERF") == "1"
 
 	// Initialize WebSocket hub
 	hub = newHub()
@@ -55,6 +107,7 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Compress(5))
+	r.Use(PerfMiddleware)
 
 	// Health check (no auth)
 	r.Get("/health", HandleHealth)
@@ -62,15 +115,31 @@ func main() {
 	// WebSocket (no auth - dashboard uses it)
 	r.Get("/ws", HandleWebSocket)
 
-	// API routes (require API key)
+	// API routes
 	r.Route("/api", func(r chi.Router) {
-		r.Use(APIKeyAuth(apiKey))
-
-		r.Post("/logs", HandlePostLogs)
-		r.Get("/logs", HandleGetLogs)
-		r.Delete("/logs", HandleClearLogs)
-		r.Get("/scanners", HandleGetScanners)
-		r.Get("/stats", HandleGetStats)
+		// Enrollment is gated by its own one-time token, not the admin key
+		r.Post("/enroll", HandleEnroll)
+
+		// Scanner-facing ingest endpoints authenticate each scanner
+		// individually instead of trusting a single shared key.
+		r.Group(func(r chi.Router) {
+			r.Use(ScannerAuth)
+
+			r.Post("/logs", HandlePostLogs)
+			r.Get("/scanners/{id}/checkpoint", HandleGetCheckpoint)
+		})
+
+		// Dashboard/admin endpoints still use the shared API key
+		r.Group(func(r chi.Router) {
+			r.Use(APIKeyAuth(apiKey))
+
+			r.Get("/logs", HandleGetLogs)
+			r.Delete("/logs", HandleClearLogs)
+			r.Get("/scanners", HandleGetScanners)
+			r.Delete("/scanners/{id}", HandleRevokeScanner)
+			r.Get("/stats", HandleGetStats)
+			r.Get("/debug/perf", HandleDebugPerf)
+		})
 	})
 
 	// Static files (dashboard)
@@ -85,7 +154,75 @@ func main() {
 	log.Printf("Dashboard: http://localhost:%s/", port)
 	log.Printf("API: http://localhost:%s/api/", port)
 
+	if tlsConfig := buildTLSConfig(); tlsConfig != nil {
+		server := &http.Server{Addr: ":" + port, Handler: r, TLSConfig: tlsConfig}
+		// Cert/key are still loaded from file by ListenAndServeTLS; only the
+		// client-auth policy comes from tlsConfig.
+		if err := server.ListenAndServeTLS(os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := http.ListenAndServe(":"+port, r); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// buildTLSConfig returns a TLS config requiring a server certificate when
+// TLS_CERT_FILE/TLS_KEY_FILE are set. When TLS_CLIENT_CA is also set, it
+// verifies a client certificate against that CA *if one is presented*
+// (VerifyClientCertIfGiven), rather than requiring one for every
+// connection - a single http.Server/listener serves both scanner and
+// non-scanner routes, and requiring a client cert at the TLS layer would
+// lock out /health, the dashboard, and POST /api/enroll (the very endpoint
+// a scanner uses to get its first credential). ScannerAuth is what actually
+// requires and enforces the cert for scanner-facing routes, binding scanner
+// identity to the certificate's CN instead of a bearer credential.
+// Returns nil if TLS isn't configured.
+func buildTLSConfig() *tls.Config {
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile := os.Getenv("TLS_KEY_FILE")
+	if certFile == "" || keyFile == "" {
+		return nil
+	}
+
+	cfg := &tls.Config{}
+
+	clientCAPath := os.Getenv("TLS_CLIENT_CA")
+	if clientCAPath == "" {
+		return cfg
+	}
+
+	caCert, err := os.ReadFile(clientCAPath)
+	if err != nil {
+		log.Fatalf("Failed to read TLS_CLIENT_CA: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		log.Fatalf("Failed to parse TLS_CLIENT_CA: %s", clientCAPath)
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	requireMTLS = true
+	log.Println("mTLS enabled: scanner identity is bound to client certificate CN")
+
+	return cfg
+}
+
+// envFloat reads a float64 from an environment variable, falling back to
+// def if it's unset or not a valid number.
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %v", name, v, def)
+		return def
+	}
+	return parsed
+}
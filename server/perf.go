@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// routeStats holds the latency histogram and byte counters for one route.
+// A histogram (rather than a running average) is what lets GET
+// /api/debug/perf report p95/p99, not just a single number like
+// middleware.Logger gives per request.
+type routeStats struct {
+	mu       sync.Mutex
+	hist     *hdrhistogram.Histogram
+	count    int64
+	bytesIn  int64
+	bytesOut int64
+}
+
+// newRouteStats tracks latencies from 1 microsecond to 60 seconds at 3
+// significant figures, which is plenty of resolution for HTTP handlers.
+func newRouteStats() *routeStats {
+	return &routeStats{hist: hdrhistogram.New(1, 60_000_000, 3)}
+}
+
+func (s *routeStats) record(dur time.Duration, bytesIn, bytesOut int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hist.RecordValue(dur.Microseconds())
+	s.count++
+	s.bytesIn += bytesIn
+	s.bytesOut += bytesOut
+}
+
+func (s *routeStats) snapshot() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]interface{}{
+		"count":     s.count,
+		"p50_ms":    microsToMs(s.hist.ValueAtQuantile(50)),
+		"p95_ms":    microsToMs(s.hist.ValueAtQuantile(95)),
+		"p99_ms":    microsToMs(s.hist.ValueAtQuantile(99)),
+		"bytes_in":  s.bytesIn,
+		"bytes_out": s.bytesOut,
+	}
+}
+
+func microsToMs(micros int64) float64 {
+	return float64(micros) / 1000
+}
+
+// PerfRegistry collects per-route latency and throughput histograms for the
+// GET /api/debug/perf snapshot.
+type PerfRegistry struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+func newPerfRegistry() *PerfRegistry {
+	return &PerfRegistry{routes: make(map[string]*routeStats)}
+}
+
+func (p *PerfRegistry) record(route string, dur time.Duration, bytesIn, bytesOut int64) {
+	p.mu.Lock()
+	stats, ok := p.routes[route]
+	if !ok {
+		stats = newRouteStats()
+		p.routes[route] = stats
+	}
+	p.mu.Unlock()
+
+	stats.record(dur, bytesIn, bytesOut)
+}
+
+// Snapshot returns a JSON-ready map of route -> {count, p50_ms, p95_ms,
+// p99_ms, bytes_in, bytes_out}.
+func (p *PerfRegistry) Snapshot() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make(map[string]interface{}, len(p.routes))
+	for route, stats := range p.routes {
+		out[route] = stats.snapshot()
+	}
+	return out
+}
+
+var perfRegistry = newPerfRegistry()
+
+// PerfMiddleware records a latency/bytes sample for every request into
+// perfRegistry, keyed by "METHOD route-pattern" (e.g. "POST /api/logs").
+// It complements middleware.Logger's single per-request latency line with
+// a queryable distribution across all routes.
+func PerfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := r.URL.Path
+		if rc := chi.RouteContext(r.Context()); rc != nil {
+			if pattern := rc.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+
+		bytesIn := r.ContentLength
+		if bytesIn < 0 {
+			bytesIn = 0
+		}
+
+		perfRegistry.record(r.Method+" "+route, time.Since(start), bytesIn, int64(ww.BytesWritten()))
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a minimal token-bucket limiter: it refills continuously at
+// rate tokens/sec up to burst, and each Allow() call either spends one
+// token or reports how long the caller must wait for one to accrue.
+type tokenBucket struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, lastRefill: time.Now()}
+}
+
+// Allow reports whether a token is available, spending it if so. If not, it
+// returns the wait until one token will have accrued.
+func (b *tokenBucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.lastRefill).Seconds()*b.rate)
+	b.lastRefill = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// IngestLimiter rate-limits the ingest endpoint per scanner, so one noisy
+// or misconfigured scanner can't starve the others.
+type IngestLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	rate    float64
+	burst   float64
+}
+
+// NewIngestLimiter creates a limiter allowing `rate` batches/sec per
+// scanner, with bursts up to `burst`.
+func NewIngestLimiter(rate, burst float64) *IngestLimiter {
+	return &IngestLimiter{
+		buckets: make(map[string]*tokenBucket),
+		rate:    rate,
+		burst:   burst,
+	}
+}
+
+// Allow reports whether scannerID may send a batch now, spending a token if
+// so, or the wait until it may retry if not.
+func (l *IngestLimiter) Allow(scannerID string) (bool, time.Duration) {
+	l.mu.Lock()
+	b, ok := l.buckets[scannerID]
+	if !ok {
+		b = newTokenBucket(l.rate, l.burst)
+		l.buckets[scannerID] = b
+	}
+	l.mu.Unlock()
+
+	return b.Allow()
+}
+
+// Snapshot reports how many scanners are tracked and how many are
+// currently out of tokens, for merging into the dashboard stats by
+// currentStats.
+func (l *IngestLimiter) Snapshot() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	throttled := 0
+	for _, b := range l.buckets {
+		b.mu.Lock()
+		tokens := math.Min(b.burst, b.tokens+now.Sub(b.lastRefill).Seconds()*b.rate)
+		if tokens < 1 {
+			throttled++
+		}
+		b.mu.Unlock()
+	}
+
+	return map[string]interface{}{
+		"rate_limited_scanners": throttled,
+		"tracked_scanners":      len(l.buckets),
+	}
+}
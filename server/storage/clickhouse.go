@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// clickhouseStore targets large deployments: dozens of scanners each
+// pushing RSSI samples at 1Hz quickly exceed what a single SQLite writer
+// can absorb, and ClickHouse's columnar layout fits this append-only
+// time-series shape well. Scanner/credential state is small and mutable, so
+// those tables use ReplacingMergeTree keyed on id to emulate upserts.
+type clickhouseStore struct {
+	db *sql.DB
+}
+
+func newClickHouseStore(dsn string) (Store, error) {
+	db, err := sql.Open("clickhouse", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("connecting to clickhouse: %w", err)
+	}
+
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS scanners (
+			id String,
+			name String,
+			last_seen DateTime64(3),
+			ip_address String,
+			created_at DateTime64(3) DEFAULT now64(3),
+			last_sequence UInt64 DEFAULT 0,
+			version UInt64 DEFAULT 1
+		) ENGINE = ReplacingMergeTree(version)
+		ORDER BY id`,
+
+		`CREATE TABLE IF NOT EXISTS logs (
+			scanner_id String,
+			timestamp DateTime64(3),
+			mac String,
+			name String,
+			rssi Int32,
+			device_type String,
+			status String,
+			manufacturer String,
+			created_at DateTime64(3) DEFAULT now64(3)
+		) ENGINE = MergeTree()
+		PARTITION BY toYYYYMM(timestamp)
+		ORDER BY (scanner_id, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS scanner_credentials (
+			scanner_id String,
+			secret_hash String,
+			cert_fingerprint String,
+			created_at DateTime64(3) DEFAULT now64(3),
+			revoked_at Nullable(DateTime64(3)),
+			version UInt64 DEFAULT 1
+		) ENGINE = ReplacingMergeTree(version)
+		ORDER BY scanner_id`,
+	}
+
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Println("ClickHouse storage initialized")
+	return &clickhouseStore{db: db}, nil
+}
+
+func (s *clickhouseStore) Close() error {
+	return s.db.Close()
+}
+
+// UpsertScanner inserts a new version of the scanner row; ReplacingMergeTree
+// reconciles duplicate ids down to the highest `version` in the background.
+// Reads that need the merged view query FINAL (see GetScanners).
+func (s *clickhouseStore) UpsertScanner(id, name, ip string) error {
+	existing, err := s.getScannerRow(id)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil && name == "" {
+		name = existing.Name
+	}
+	version := uint64(1)
+	if existing != nil {
+		version = existing.version + 1
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO scanners (id, name, last_seen, ip_address, last_sequence, version)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, id, name, time.Now(), ip, lastSeqOr(existing), version)
+	return err
+}
+
+type scannerRow struct {
+	Scanner
+	version uint64
+}
+
+func lastSeqOr(existing *scannerRow) uint64 {
+	if existing == nil {
+		return 0
+	}
+	return existing.LastSequence
+}
+
+func (s *clickhouseStore) getScannerRow(id string) (*scannerRow, error) {
+	var row scannerRow
+	err := s.db.QueryRow(`
+		SELECT id, name, last_seen, ip_address, created_at, last_sequence, version
+		FROM scanners FINAL
+		WHERE id = ?
+	`, id).Scan(&row.ID, &row.Name, &row.LastSeen, &row.IPAddress, &row.CreatedAt, &row.LastSequence, &row.version)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// InsertLogBatch appends log rows and, once they are committed, inserts a
+// new scanners version carrying the bumped sequence if the batch advances
+// it. The two inserts are separate ClickHouse writes, not one transaction:
+// a crash or error between them leaves the rows stored with the sequence
+// not advanced, so the scanner resends the batch and its rows are stored
+// twice; rows are never dropped this way. Batches at-or-below the scanner's current sequence are idempotently
+// ignored rather than re-inserted, so a retransmit of an already-applied
+// batch never duplicates rows - this is enforced here, not just by
+// HandlePostLogs's own pre-check, so the guarantee holds for any caller of
+// the Store interface.
+func (s *clickhouseStore) InsertLogBatch(batch LogBatch) (int, error) {
+	existing, err := s.getScannerRow(batch.ScannerID)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil && batch.Sequence <= existing.LastSequence {
+		return 0, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO logs (scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	count := 0
+	for _, entry := range batch.Devices {
+		ts := entry.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		if _, err := stmt.Exec(batch.ScannerID, ts, entry.MAC, entry.Name, entry.RSSI, entry.DeviceType, entry.Status, entry.Manufacturer); err != nil {
+			log.Printf("Error inserting log entry: %v", err)
+			continue
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if existing != nil && batch.Sequence > existing.LastSequence {
+		if _, err := s.db.Exec(`
+			INSERT INTO scanners (id, name, last_seen, ip_address, last_sequence, version)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, existing.ID, existing.Name, existing.LastSeen, existing.IPAddress, batch.Sequence, existing.version+1); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func (s *clickhouseStore) GetScannerSequence(scannerID string) (uint64, error) {
+	row, err := s.getScannerRow(scannerID)
+	if err != nil {
+		return 0, err
+	}
+	if row == nil {
+		return 0, nil
+	}
+	return row.LastSequence, nil
+}
+
+func (s *clickhouseStore) InsertScannerCredential(scannerID, secretHash, certFingerprint string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO scanner_credentials (scanner_id, secret_hash, cert_fingerprint, version)
+		VALUES (?, ?, ?, 1)
+	`, scannerID, secretHash, certFingerprint)
+	return err
+}
+
+func (s *clickhouseStore) GetScannerCredential(scannerID string) (*ScannerCredential, error) {
+	var cred ScannerCredential
+	var revokedAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT scanner_id, secret_hash, cert_fingerprint, created_at, revoked_at
+		FROM scanner_credentials FINAL
+		WHERE scanner_id = ?
+	`, scannerID).Scan(&cred.ScannerID, &cred.SecretHash, &cred.CertFingerprint, &cred.CreatedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cred.RevokedAt = revokedAt
+	return &cred, nil
+}
+
+func (s *clickhouseStore) RevokeScanner(scannerID string) error {
+	rows, err := s.db.Query(`
+		SELECT scanner_id, secret_hash, cert_fingerprint, created_at, version
+		FROM scanner_credentials FINAL
+		WHERE scanner_id = ? AND revoked_at IS NULL
+	`, scannerID)
+	if err != nil {
+		return err
+	}
+
+	var scannerID2, secretHash, certFingerprint string
+	var createdAt time.Time
+	var version uint64
+	found := false
+	for rows.Next() {
+		if err := rows.Scan(&scannerID2, &secretHash, &certFingerprint, &createdAt, &version); err != nil {
+			rows.Close()
+			return err
+		}
+		found = true
+	}
+	rows.Close()
+
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO scanner_credentials (scanner_id, secret_hash, cert_fingerprint, created_at, revoked_at, version)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, scannerID2, secretHash, certFingerprint, createdAt, time.Now(), version+1)
+	return err
+}
+
+func (s *clickhouseStore) GetRecentLogs(limit int) ([]LogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer, created_at
+		FROM logs
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLogRowsNoID(rows)
+}
+
+func (s *clickhouseStore) GetLogsByScanner(scannerID string, limit int) ([]LogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer, created_at
+		FROM logs
+		WHERE scanner_id = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, scannerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLogRowsNoID(rows)
+}
+
+// scanLogRowsNoID reads logs rows that have no surrogate id column; ClickHouse
+// log rows are identified by (scanner_id, timestamp) rather than a sequence.
+func scanLogRowsNoID(rows *sql.Rows) ([]LogEntry, error) {
+	var logs []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		err := rows.Scan(&entry.ScannerID, &entry.Timestamp, &entry.MAC, &entry.Name,
+			&entry.RSSI, &entry.DeviceType, &entry.Status, &entry.Manufacturer, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+func (s *clickhouseStore) GetScanners() ([]Scanner, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, last_seen, ip_address, created_at, last_sequence
+		FROM scanners FINAL
+		ORDER BY last_seen DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scanners []Scanner
+	for rows.Next() {
+		var sc Scanner
+		if err := rows.Scan(&sc.ID, &sc.Name, &sc.LastSeen, &sc.IPAddress, &sc.CreatedAt, &sc.LastSequence); err != nil {
+			return nil, err
+		}
+		scanners = append(scanners, sc)
+	}
+	return scanners, nil
+}
+
+func (s *clickhouseStore) GetStats() (map[string]interface{}, error) {
+	stats := make(map[string]interface{})
+
+	var totalLogs int
+	s.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&totalLogs)
+	stats["total_logs"] = totalLogs
+
+	var uniqueDevices int
+	s.db.QueryRow("SELECT COUNT(DISTINCT mac) FROM logs").Scan(&uniqueDevices)
+	stats["unique_devices"] = uniqueDevices
+
+	var activeScanners int
+	s.db.QueryRow("SELECT COUNT(*) FROM scanners FINAL WHERE last_seen > now() - INTERVAL 5 MINUTE").Scan(&activeScanners)
+	stats["active_scanners"] = activeScanners
+
+	var logsLastHour int
+	s.db.QueryRow("SELECT COUNT(*) FROM logs WHERE created_at > now() - INTERVAL 1 HOUR").Scan(&logsLastHour)
+	stats["logs_last_hour"] = logsLastHour
+
+	return stats, nil
+}
+
+// ClearLogs deletes every row of the logs table with an ALTER TABLE ...
+// DELETE mutation and returns the row count read just before it.
+func (s *clickhouseStore) ClearLogs() (int64, error) {
+	var before int64
+	s.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&before)
+
+	if _, err := s.db.Exec("ALTER TABLE logs DELETE WHERE 1 = 1"); err != nil {
+		return 0, err
+	}
+	return before, nil
+}
@@ -0,0 +1,26 @@
+//go:build clickhouse
+
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+// Requires a live ClickHouse instance; run with:
+//
+//	TEST_CLICKHOUSE_DSN=clickhouse://localhost:9000/ble_test go test -tags clickhouse ./storage/...
+func TestClickHouseStoreBehavior(t *testing.T) {
+	dsn := os.Getenv("TEST_CLICKHOUSE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_CLICKHOUSE_DSN not set")
+	}
+
+	store, err := newClickHouseStore(dsn)
+	if err != nil {
+		t.Fatalf("newClickHouseStore: %v", err)
+	}
+	defer store.Close()
+
+	testStoreBehavior(t, store)
+}
@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresStore is for deployments that outgrow SQLite's single-writer
+// throughput. The logs table is range-partitioned by month on timestamp so
+// old partitions can be dropped or archived independently of live ingest.
+type postgresStore struct {
+	db *sql.DB
+}
+
+func newPostgresStore(dsn string) (Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS scanners (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		last_seen TIMESTAMPTZ,
+		ip_address TEXT,
+		created_at TIMESTAMPTZ DEFAULT now(),
+		last_sequence BIGINT NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS logs (
+		id BIGSERIAL,
+		scanner_id TEXT NOT NULL REFERENCES scanners(id),
+		timestamp TIMESTAMPTZ NOT NULL,
+		mac TEXT NOT NULL,
+		name TEXT,
+		rssi INTEGER,
+		device_type TEXT,
+		status TEXT,
+		manufacturer TEXT,
+		created_at TIMESTAMPTZ DEFAULT now(),
+		PRIMARY KEY (id, timestamp)
+	) PARTITION BY RANGE (timestamp);
+
+	-- Catch-all partition for rows outside any explicit monthly partition
+	-- (e.g. clock-skewed scanners); ensureMonthPartition creates the current
+	-- month's partition eagerly so hot-path inserts normally land there.
+	CREATE TABLE IF NOT EXISTS logs_default PARTITION OF logs DEFAULT;
+
+	CREATE INDEX IF NOT EXISTS idx_logs_scanner ON logs(scanner_id);
+	CREATE INDEX IF NOT EXISTS idx_logs_mac ON logs(mac);
+	CREATE INDEX IF NOT EXISTS idx_logs_created ON logs(created_at);
+
+	CREATE TABLE IF NOT EXISTS scanner_credentials (
+		scanner_id TEXT PRIMARY KEY REFERENCES scanners(id),
+		secret_hash TEXT NOT NULL,
+		cert_fingerprint TEXT,
+		created_at TIMESTAMPTZ DEFAULT now(),
+		revoked_at TIMESTAMPTZ
+	);
+	`
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+
+	store := &postgresStore{db: db}
+	if err := store.ensureMonthPartition(time.Now()); err != nil {
+		return nil, err
+	}
+
+	log.Println("Postgres storage initialized")
+	return store, nil
+}
+
+// ensureMonthPartition creates the logs partition covering t's calendar
+// month if it doesn't already exist.
+func (s *postgresStore) ensureMonthPartition(t time.Time) error {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	name := fmt.Sprintf("logs_y%04dm%02d", start.Year(), start.Month())
+
+	_, err := s.db.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s PARTITION OF logs
+		FOR VALUES FROM ('%s') TO ('%s')
+	`, name, start.Format(time.RFC3339), end.Format(time.RFC3339)))
+	return err
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *postgresStore) UpsertScanner(id, name, ip string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO scanners (id, name, last_seen, ip_address)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET
+			name = COALESCE(NULLIF(EXCLUDED.name, ''), scanners.name),
+			last_seen = EXCLUDED.last_seen,
+			ip_address = EXCLUDED.ip_address
+	`, id, name, time.Now(), ip)
+	return err
+}
+
+// InsertLogBatch inserts the batch's rows and advances the scanner's
+// checkpoint sequence in the same transaction. Batches at-or-below the
+// scanner's current sequence are idempotently ignored rather than
+// re-inserted, so a retransmit of an already-applied batch never
+// duplicates rows - this is enforced here, not just by HandlePostLogs's
+// own pre-check, so the guarantee holds for any caller of the Store
+// interface.
+func (s *postgresStore) InsertLogBatch(batch LogBatch) (int, error) {
+	if err := s.ensureMonthPartition(time.Now()); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var lastSeq uint64
+	if err := tx.QueryRow(`SELECT last_sequence FROM scanners WHERE id = $1`, batch.ScannerID).Scan(&lastSeq); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if batch.Sequence <= lastSeq {
+		return 0, nil
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO logs (scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	count := 0
+	for _, entry := range batch.Devices {
+		ts := entry.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		if _, err := stmt.Exec(batch.ScannerID, ts, entry.MAC, entry.Name, entry.RSSI, entry.DeviceType, entry.Status, entry.Manufacturer); err != nil {
+			log.Printf("Error inserting log entry: %v", err)
+			continue
+		}
+		count++
+	}
+
+	// Only advance the checkpoint if every row in the batch was inserted;
+	// otherwise the scanner would believe rows it never successfully sent
+	// are durable and would never resend them.
+	if count == len(batch.Devices) {
+		if _, err := tx.Exec(`
+			UPDATE scanners SET last_sequence = $1 WHERE id = $2 AND last_sequence < $1
+		`, batch.Sequence, batch.ScannerID); err != nil {
+			return 0, err
+		}
+	} else {
+		log.Printf("ERROR: batch %s from scanner %s only inserted %d/%d rows; withholding sequence advance past %d so the scanner resends",
+			batch.BatchID, batch.ScannerID, count, len(batch.Devices), batch.Sequence)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *postgresStore) GetScannerSequence(scannerID string) (uint64, error) {
+	var seq uint64
+	err := s.db.QueryRow(`SELECT last_sequence FROM scanners WHERE id = $1`, scannerID).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+func (s *postgresStore) InsertScannerCredential(scannerID, secretHash, certFingerprint string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO scanner_credentials (scanner_id, secret_hash, cert_fingerprint)
+		VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (scanner_id) DO UPDATE SET
+			secret_hash = excluded.secret_hash,
+			cert_fingerprint = excluded.cert_fingerprint,
+			revoked_at = NULL
+	`, scannerID, secretHash, certFingerprint)
+	return err
+}
+
+func (s *postgresStore) GetScannerCredential(scannerID string) (*ScannerCredential, error) {
+	var cred ScannerCredential
+	var fingerprint sql.NullString
+	err := s.db.QueryRow(`
+		SELECT scanner_id, secret_hash, cert_fingerprint, created_at, revoked_at
+		FROM scanner_credentials
+		WHERE scanner_id = $1
+	`, scannerID).Scan(&cred.ScannerID, &cred.SecretHash, &fingerprint, &cred.CreatedAt, &cred.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cred.CertFingerprint = fingerprint.String
+	return &cred, nil
+}
+
+func (s *postgresStore) RevokeScanner(scannerID string) error {
+	result, err := s.db.Exec(`
+		UPDATE scanner_credentials SET revoked_at = now()
+		WHERE scanner_id = $1 AND revoked_at IS NULL
+	`, scannerID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *postgresStore) GetRecentLogs(limit int) ([]LogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer, created_at
+		FROM logs
+		ORDER BY created_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLogRows(rows)
+}
+
+func (s *postgresStore) GetLogsByScanner(scannerID string, limit int) ([]LogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer, created_at
+		FROM logs
+		WHERE scanner_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, scannerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLogRows(rows)
+}
+
+func scanLogRows(rows *sql.Rows) ([]LogEntry, error) {
+	var logs []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		err := rows.Scan(&entry.ID, &entry.ScannerID, &entry.Timestamp, &entry.MAC, &entry.Name,
+			&entry.RSSI, &entry.DeviceType, &entry.Status, &entry.Manufacturer, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+func (s *postgresStore) GetScanners() ([]Scanner, error) {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(name, ''), last_seen, COALESCE(ip_address, ''), created_at, last_sequence
+		FROM scanners
+		ORDER BY last_seen DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scanners []Scanner
+	for rows.Next() {
+		var sc Scanner
+		if err := rows.Scan(&sc.ID, &sc.Name, &sc.LastSeen, &sc.IPAddress, &sc.CreatedAt, &sc.LastSequence); err != nil {
+			return nil, err
+		}
+		scanners = append(scanners, sc)
+	}
+	return scanners, nil
+}
+
+func (s *postgresStore) GetStats() (map[string]interface{}, error) {
+	stats := make(map[string]interface{})
+
+	var totalLogs int
+	s.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&totalLogs)
+	stats["total_logs"] = totalLogs
+
+	var uniqueDevices int
+	s.db.QueryRow("SELECT COUNT(DISTINCT mac) FROM logs").Scan(&uniqueDevices)
+	stats["unique_devices"] = uniqueDevices
+
+	var activeScanners int
+	s.db.QueryRow("SELECT COUNT(*) FROM scanners WHERE last_seen > now() - interval '5 minutes'").Scan(&activeScanners)
+	stats["active_scanners"] = activeScanners
+
+	var logsLastHour int
+	s.db.QueryRow("SELECT COUNT(*) FROM logs WHERE created_at > now() - interval '1 hour'").Scan(&logsLastHour)
+	stats["logs_last_hour"] = logsLastHour
+
+	return stats, nil
+}
+
+func (s *postgresStore) ClearLogs() (int64, error) {
+	result, err := s.db.Exec("DELETE FROM logs")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
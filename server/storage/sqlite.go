@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteStore is the default Store backend. It's a fine fit for a single
+// server with at most a handful of scanners; see postgresStore and
+// clickhouseStore for deployments that outgrow SQLite's write throughput.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(dbPath string) (Store, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS scanners (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		last_seen DATETIME,
+		ip_address TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		scanner_id TEXT NOT NULL,
+		timestamp DATETIME,
+		mac TEXT NOT NULL,
+		name TEXT,
+		rssi INTEGER,
+		device_type TEXT,
+		status TEXT,
+		manufacturer TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (scanner_id) REFERENCES scanners(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_logs_scanner ON logs(scanner_id);
+	CREATE INDEX IF NOT EXISTS idx_logs_mac ON logs(mac);
+	CREATE INDEX IF NOT EXISTS idx_logs_created ON logs(created_at);
+
+	CREATE TABLE IF NOT EXISTS scanner_credentials (
+		scanner_id TEXT PRIMARY KEY,
+		secret_hash TEXT NOT NULL,
+		cert_fingerprint TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked_at DATETIME,
+		FOREIGN KEY (scanner_id) REFERENCES scanners(id)
+	);
+	`
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+
+	// Track the highest batch sequence number accepted per scanner so the
+	// ingest endpoint can detect gaps and tell scanners where to resume.
+	// Added after the initial release, so existing installations pick it up
+	// via ALTER TABLE rather than a fresh CREATE TABLE.
+	if _, err := db.Exec(`ALTER TABLE scanners ADD COLUMN last_sequence INTEGER NOT NULL DEFAULT 0`); err != nil {
+		if !strings.Contains(err.Error(), "duplicate column") {
+			return nil, err
+		}
+	}
+
+	log.Println("Database initialized")
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// UpsertScanner creates or updates a scanner record
+func (s *sqliteStore) UpsertScanner(id, name, ip string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO scanners (id, name, last_seen, ip_address)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = COALESCE(NULLIF(excluded.name, ''), scanners.name),
+			last_seen = excluded.last_seen,
+			ip_address = excluded.ip_address
+	`, id, name, time.Now(), ip)
+	return err
+}
+
+// InsertLogBatch inserts multiple log entries and advances the scanner's
+// checkpoint sequence in a single transaction, so a crash between the two
+// can never leave them out of sync. Batches at-or-below the scanner's
+// current sequence are idempotently ignored rather than re-inserted, so a
+// retransmit of an already-applied batch never duplicates rows - this is
+// enforced here, not just by HandlePostLogs's own pre-check, so the
+// guarantee holds for any caller of the Store interface.
+func (s *sqliteStore) InsertLogBatch(batch LogBatch) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var lastSeq uint64
+	if err := tx.QueryRow(`SELECT last_sequence FROM scanners WHERE id = ?`, batch.ScannerID).Scan(&lastSeq); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if batch.Sequence <= lastSeq {
+		return 0, nil
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO logs (scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	count := 0
+	for _, entry := range batch.Devices {
+		ts := entry.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		_, err := stmt.Exec(batch.ScannerID, ts, entry.MAC, entry.Name, entry.RSSI, entry.DeviceType, entry.Status, entry.Manufacturer)
+		if err != nil {
+			log.Printf("Error inserting log entry: %v", err)
+			continue
+		}
+		count++
+	}
+
+	// Only advance the checkpoint if every row in the batch was inserted;
+	// otherwise the scanner would believe rows it never successfully sent
+	// are durable and would never resend them.
+	if count == len(batch.Devices) {
+		if _, err := tx.Exec(`
+			UPDATE scanners SET last_sequence = ? WHERE id = ? AND last_sequence < ?
+		`, batch.Sequence, batch.ScannerID, batch.Sequence); err != nil {
+			return 0, err
+		}
+	} else {
+		log.Printf("ERROR: batch %s from scanner %s only inserted %d/%d rows; withholding sequence advance past %d so the scanner resends",
+			batch.BatchID, batch.ScannerID, count, len(batch.Devices), batch.Sequence)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetScannerSequence returns the highest batch sequence accepted so far for
+// a scanner, or 0 if the scanner hasn't sent anything yet.
+func (s *sqliteStore) GetScannerSequence(scannerID string) (uint64, error) {
+	var seq uint64
+	err := s.db.QueryRow(`SELECT last_sequence FROM scanners WHERE id = ?`, scannerID).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// InsertScannerCredential stores the hashed secret issued during enrollment,
+// optionally pinning it to certFingerprint
+func (s *sqliteStore) InsertScannerCredential(scannerID, secretHash, certFingerprint string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO scanner_credentials (scanner_id, secret_hash, cert_fingerprint)
+		VALUES (?, ?, NULLIF(?, ''))
+		ON CONFLICT(scanner_id) DO UPDATE SET
+			secret_hash = excluded.secret_hash,
+			cert_fingerprint = excluded.cert_fingerprint,
+			revoked_at = NULL
+	`, scannerID, secretHash, certFingerprint)
+	return err
+}
+
+// GetScannerCredential looks up a scanner's credential, or returns nil if
+// the scanner was never enrolled
+func (s *sqliteStore) GetScannerCredential(scannerID string) (*ScannerCredential, error) {
+	var cred ScannerCredential
+	err := s.db.QueryRow(`
+		SELECT scanner_id, secret_hash, COALESCE(cert_fingerprint, ''), created_at, revoked_at
+		FROM scanner_credentials
+		WHERE scanner_id = ?
+	`, scannerID).Scan(&cred.ScannerID, &cred.SecretHash, &cred.CertFingerprint, &cred.CreatedAt, &cred.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// RevokeScanner marks a scanner's credential as revoked so it can no longer
+// authenticate; the scanner and its historical logs are left in place
+func (s *sqliteStore) RevokeScanner(scannerID string) error {
+	result, err := s.db.Exec(`
+		UPDATE scanner_credentials SET revoked_at = CURRENT_TIMESTAMP
+		WHERE scanner_id = ? AND revoked_at IS NULL
+	`, scannerID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetRecentLogs returns the most recent log entries
+func (s *sqliteStore) GetRecentLogs(limit int) ([]LogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer, created_at
+		FROM logs
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		err := rows.Scan(&entry.ID, &entry.ScannerID, &entry.Timestamp, &entry.MAC, &entry.Name,
+			&entry.RSSI, &entry.DeviceType, &entry.Status, &entry.Manufacturer, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+// GetLogsByScanner returns logs for a specific scanner
+func (s *sqliteStore) GetLogsByScanner(scannerID string, limit int) ([]LogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scanner_id, timestamp, mac, name, rssi, device_type, status, manufacturer, created_at
+		FROM logs
+		WHERE scanner_id = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, scannerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		err := rows.Scan(&entry.ID, &entry.ScannerID, &entry.Timestamp, &entry.MAC, &entry.Name,
+			&entry.RSSI, &entry.DeviceType, &entry.Status, &entry.Manufacturer, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+// GetScanners returns all registered scanners
+func (s *sqliteStore) GetScanners() ([]Scanner, error) {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(name, ''), last_seen, COALESCE(ip_address, ''), created_at, last_sequence
+		FROM scanners
+		ORDER BY last_seen DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scanners []Scanner
+	for rows.Next() {
+		var sc Scanner
+		err := rows.Scan(&sc.ID, &sc.Name, &sc.LastSeen, &sc.IPAddress, &sc.CreatedAt, &sc.LastSequence)
+		if err != nil {
+			return nil, err
+		}
+		scanners = append(scanners, sc)
+	}
+	return scanners, nil
+}
+
+// GetStats returns dashboard statistics
+func (s *sqliteStore) GetStats() (map[string]interface{}, error) {
+	stats := make(map[string]interface{})
+
+	var totalLogs int
+	s.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&totalLogs)
+	stats["total_logs"] = totalLogs
+
+	var uniqueDevices int
+	s.db.QueryRow("SELECT COUNT(DISTINCT mac) FROM logs").Scan(&uniqueDevices)
+	stats["unique_devices"] = uniqueDevices
+
+	var activeScanners int
+	s.db.QueryRow("SELECT COUNT(*) FROM scanners WHERE last_seen > datetime('now', '-5 minutes')").Scan(&activeScanners)
+	stats["active_scanners"] = activeScanners
+
+	var logsLastHour int
+	s.db.QueryRow("SELECT COUNT(*) FROM logs WHERE created_at > datetime('now', '-1 hour')").Scan(&logsLastHour)
+	stats["logs_last_hour"] = logsLastHour
+
+	return stats, nil
+}
+
+// ClearLogs deletes all logs from the database
+func (s *sqliteStore) ClearLogs() (int64, error) {
+	result, err := s.db.Exec("DELETE FROM logs")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStoreBehavior(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ble-test.db")
+
+	store, err := newSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("newSQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	testStoreBehavior(t, store)
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"testing"
+)
+
+// testStoreBehavior runs the same behavioral assertions against any Store
+// implementation, so sqlite/postgres/clickhouse stay interchangeable from
+// the handlers' point of view. Each backend's _test.go file (gated by its
+// own build tag where a live server is required) calls this with a fresh
+// Store.
+func testStoreBehavior(t *testing.T, store Store) {
+	t.Helper()
+
+	const scannerID = "test-scanner-1"
+
+	if err := store.UpsertScanner(scannerID, "Test Scanner", "127.0.0.1"); err != nil {
+		t.Fatalf("UpsertScanner: %v", err)
+	}
+
+	seq, err := store.GetScannerSequence(scannerID)
+	if err != nil {
+		t.Fatalf("GetScannerSequence (new scanner): %v", err)
+	}
+	if seq != 0 {
+		t.Fatalf("GetScannerSequence (new scanner) = %d, want 0", seq)
+	}
+
+	batch1 := LogBatch{
+		ScannerID: scannerID,
+		Sequence:  1,
+		BatchID:   "batch-1",
+		Devices: []LogEntry{
+			{MAC: "aa:bb:cc:dd:ee:01", Name: "Device A", RSSI: -60, DeviceType: "ble", Status: "new"},
+			{MAC: "aa:bb:cc:dd:ee:02", Name: "Device B", RSSI: -70, DeviceType: "ble", Status: "new"},
+		},
+	}
+
+	count, err := store.InsertLogBatch(batch1)
+	if err != nil {
+		t.Fatalf("InsertLogBatch(batch1): %v", err)
+	}
+	if count != len(batch1.Devices) {
+		t.Fatalf("InsertLogBatch(batch1) count = %d, want %d", count, len(batch1.Devices))
+	}
+
+	seq, err = store.GetScannerSequence(scannerID)
+	if err != nil {
+		t.Fatalf("GetScannerSequence (after batch1): %v", err)
+	}
+	if seq != 1 {
+		t.Fatalf("GetScannerSequence (after batch1) = %d, want 1", seq)
+	}
+
+	// Replaying the same sequence must be idempotent: no new rows, sequence
+	// unchanged. HandlePostLogs relies on this to ignore stale retransmits
+	// instead of erroring.
+	count, err = store.InsertLogBatch(batch1)
+	if err != nil {
+		t.Fatalf("InsertLogBatch(batch1 replay): %v", err)
+	}
+	logs, err := store.GetLogsByScanner(scannerID, 100)
+	if err != nil {
+		t.Fatalf("GetLogsByScanner: %v", err)
+	}
+	if len(logs) != len(batch1.Devices) {
+		t.Fatalf("GetLogsByScanner after replay returned %d rows, want %d (replay must not duplicate)", len(logs), len(batch1.Devices))
+	}
+	_ = count
+
+	batch2 := LogBatch{
+		ScannerID: scannerID,
+		Sequence:  2,
+		BatchID:   "batch-2",
+		Devices: []LogEntry{
+			{MAC: "aa:bb:cc:dd:ee:03", Name: "Device C", RSSI: -50, DeviceType: "ble", Status: "new"},
+		},
+	}
+	if _, err := store.InsertLogBatch(batch2); err != nil {
+		t.Fatalf("InsertLogBatch(batch2): %v", err)
+	}
+
+	seq, err = store.GetScannerSequence(scannerID)
+	if err != nil {
+		t.Fatalf("GetScannerSequence (after batch2): %v", err)
+	}
+	if seq != 2 {
+		t.Fatalf("GetScannerSequence (after batch2) = %d, want 2", seq)
+	}
+
+	recent, err := store.GetRecentLogs(10)
+	if err != nil {
+		t.Fatalf("GetRecentLogs: %v", err)
+	}
+	if len(recent) != 3 {
+		t.Fatalf("GetRecentLogs returned %d rows, want 3", len(recent))
+	}
+
+	scanners, err := store.GetScanners()
+	if err != nil {
+		t.Fatalf("GetScanners: %v", err)
+	}
+	found := false
+	for _, sc := range scanners {
+		if sc.ID == scannerID {
+			found = true
+			if sc.LastSequence != 2 {
+				t.Fatalf("GetScanners last_sequence = %d, want 2", sc.LastSequence)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("GetScanners did not include %s", scannerID)
+	}
+
+	if _, err := store.GetStats(); err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+
+	// Credentials: insert, fetch, revoke, fetch again.
+	if err := store.InsertScannerCredential(scannerID, "hashed-secret", ""); err != nil {
+		t.Fatalf("InsertScannerCredential: %v", err)
+	}
+	cred, err := store.GetScannerCredential(scannerID)
+	if err != nil {
+		t.Fatalf("GetScannerCredential: %v", err)
+	}
+	if cred == nil || cred.SecretHash != "hashed-secret" {
+		t.Fatalf("GetScannerCredential = %+v, want secret_hash=hashed-secret", cred)
+	}
+	if cred.RevokedAt.Valid {
+		t.Fatalf("GetScannerCredential revoked_at should be unset before revocation")
+	}
+
+	if err := store.RevokeScanner(scannerID); err != nil {
+		t.Fatalf("RevokeScanner: %v", err)
+	}
+	cred, err = store.GetScannerCredential(scannerID)
+	if err != nil {
+		t.Fatalf("GetScannerCredential (after revoke): %v", err)
+	}
+	if cred == nil || !cred.RevokedAt.Valid {
+		t.Fatalf("GetScannerCredential (after revoke) = %+v, want revoked_at set", cred)
+	}
+
+	deleted, err := store.ClearLogs()
+	if err != nil {
+		t.Fatalf("ClearLogs: %v", err)
+	}
+	if deleted != 3 {
+		t.Fatalf("ClearLogs deleted = %d, want 3", deleted)
+	}
+	recent, err = store.GetRecentLogs(10)
+	if err != nil {
+		t.Fatalf("GetRecentLogs (after clear): %v", err)
+	}
+	if len(recent) != 0 {
+		t.Fatalf("GetRecentLogs (after clear) = %d rows, want 0", len(recent))
+	}
+}